@@ -0,0 +1,55 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type contextKey struct{}
+
+// withKey returns a context carrying store and key, so a handler running
+// under Middleware can advance the recovery point via SetRecoveryPoint
+// without needing its own reference to either.
+func withKey(ctx context.Context, store Store, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, &contextValue{store: store, key: key})
+}
+
+type contextValue struct {
+	store Store
+	key   *Key
+}
+
+// KeyFromContext returns the idempotency key Middleware acquired for the
+// in-flight request, if any.
+func KeyFromContext(ctx context.Context) (*Key, bool) {
+	v, ok := ctx.Value(contextKey{}).(*contextValue)
+	if !ok {
+		return nil, false
+	}
+	return v.key, true
+}
+
+// errNoKeyInContext is returned by SetRecoveryPoint when called outside a
+// request that Middleware instrumented.
+var errNoKeyInContext = errors.New("idempotency: no key in context")
+
+// SetRecoveryPoint advances the recovery point of the idempotency key
+// associated with ctx and persists it immediately, so a multi-step handler
+// (e.g. create ride, then charge) can mark progress between steps: a retry
+// that crashes after this call resumes at point rather than redoing the
+// step.
+func SetRecoveryPoint(ctx context.Context, point RecoveryPoint) error {
+	v, ok := ctx.Value(contextKey{}).(*contextValue)
+	if !ok {
+		return errNoKeyInContext
+	}
+
+	v.key.RecoveryPoint = point
+	updated, err := v.store.Update(ctx, v.key)
+	if err != nil {
+		return fmt.Errorf("idempotency: advancing recovery point to %q: %w", point, err)
+	}
+	*v.key = *updated
+	return nil
+}
@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Error codes are a stable, JSON-RPC-inspired registry so clients can branch
+// on Code rather than parsing Message, which is free to change. They live
+// here, next to the sentinel errors they describe, rather than in send:
+// send already imports idempotency (FromErr needs these sentinels), so
+// Middleware's default error handling can't reach back into send for them
+// without an import cycle.
+const (
+	CodeIdempotencyConflict = 1001
+	CodeKeyLocked           = 1002
+
+	// CodeRecoveryPointUnknown is for handlers that switch on
+	// Key.RecoveryPoint and hit a value they don't recognize (e.g. a key
+	// written by a newer version of the service). ErrorFor doesn't produce
+	// it itself since that's a handler-level concern, not a sentinel this
+	// package returns.
+	CodeRecoveryPointUnknown = 1003
+
+	CodeInvalidParams = 1004
+	CodeInternal      = 1005
+)
+
+// Error is the machine-readable shape of an error response, modeled on
+// JSON-RPC's error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+
+	// HTTPStatus is the status a caller should send alongside this error.
+	// It's not part of the JSON body; the status line already carries it.
+	HTTPStatus int `json:"-"`
+}
+
+// ErrorFor maps one of this package's sentinel errors to the appropriate
+// client-facing Error. Errors it doesn't recognize map to CodeInternal/500.
+func ErrorFor(err error) *Error {
+	switch {
+	case errors.Is(err, ErrKeyConflict):
+		return &Error{
+			Code:       CodeIdempotencyConflict,
+			Message:    "idempotency key was already used with a different request",
+			HTTPStatus: http.StatusUnprocessableEntity,
+		}
+	case errors.Is(err, ErrLockNotHeld):
+		return &Error{
+			Code:       CodeKeyLocked,
+			Message:    "idempotency key is locked by another in-flight request",
+			HTTPStatus: http.StatusConflict,
+		}
+	case errors.Is(err, ErrSQLNoRows):
+		return &Error{
+			Code:       CodeInvalidParams,
+			Message:    "idempotency key not found",
+			HTTPStatus: http.StatusBadRequest,
+		}
+	default:
+		return &Error{
+			Code:       CodeInternal,
+			Message:    "internal error",
+			HTTPStatus: http.StatusInternalServerError,
+		}
+	}
+}
+
+// writeError writes e as a JSON body of the form {"error": {...}}, using
+// status rather than e.HTTPStatus, since callers of Options.OnError already
+// decide the status from more context than the error alone carries (e.g. a
+// failure in Options.UserID is a 401 regardless of what ErrorFor would pick
+// for that error by default).
+func writeError(w http.ResponseWriter, status int, e *Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error *Error `json:"error"`
+	}{e})
+}
@@ -0,0 +1,56 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+)
+
+// ErrKeyConflict is returned when an idempotency key is replayed with a
+// request whose fingerprint doesn't match the one the key was created with.
+// It means the client reused a key across two semantically different
+// requests, which the HTTP layer should reject rather than silently treat as
+// a replay.
+var ErrKeyConflict = errors.New("idempotency: key reused with a different request")
+
+// CheckConflict compares fingerprint against the fingerprint stored on k and
+// returns ErrKeyConflict on mismatch. Callers run the result of Store.Find
+// through this before treating a key as a valid replay.
+func CheckConflict(k *Key, fingerprint []byte) error {
+	if !bytes.Equal(k.RequestFingerprint, fingerprint) {
+		return ErrKeyConflict
+	}
+	return nil
+}
+
+// ComputeFingerprint hashes the canonicalized form of a request so that two
+// requests which only differ in, say, JSON key order still produce the same
+// fingerprint, while requests with a genuinely different body don't.
+func ComputeFingerprint(method, path string, body []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(canonicalizeBody(body))
+	return h.Sum(nil)
+}
+
+// canonicalizeBody re-marshals JSON bodies so semantically identical
+// requests hash identically regardless of object key order. encoding/json
+// already marshals map[string]any with keys in sorted order, recursively, so
+// a plain unmarshal/marshal round-trip is sufficient canonicalization.
+// Bodies that aren't valid JSON are hashed as-is.
+func canonicalizeBody(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
@@ -0,0 +1,49 @@
+package idempotency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeFingerprint(t *testing.T) {
+	t.Run("identical requests fingerprint the same", func(t *testing.T) {
+		a := ComputeFingerprint("POST", "/rides", []byte(`{"z":1,"a":{"y":2,"b":3}}`))
+		b := ComputeFingerprint("POST", "/rides", []byte(`{"a":{"b":3,"y":2},"z":1}`))
+		assert.Equal(t, a, b, "key order in the JSON body must not affect the fingerprint")
+	})
+
+	t.Run("a different body fingerprints differently", func(t *testing.T) {
+		a := ComputeFingerprint("POST", "/rides", []byte(`{"a":1}`))
+		b := ComputeFingerprint("POST", "/rides", []byte(`{"a":2}`))
+		assert.NotEqual(t, a, b)
+	})
+
+	t.Run("a different method or path fingerprints differently", func(t *testing.T) {
+		body := []byte(`{"a":1}`)
+		base := ComputeFingerprint("POST", "/rides", body)
+		assert.NotEqual(t, base, ComputeFingerprint("GET", "/rides", body))
+		assert.NotEqual(t, base, ComputeFingerprint("POST", "/charges", body))
+	})
+
+	t.Run("non-JSON bodies are hashed as-is", func(t *testing.T) {
+		a := ComputeFingerprint("POST", "/rides", []byte("not json"))
+		b := ComputeFingerprint("POST", "/rides", []byte("not json"))
+		assert.Equal(t, a, b)
+		assert.NotEqual(t, a, ComputeFingerprint("POST", "/rides", []byte("also not json")))
+	})
+}
+
+func TestCheckConflict(t *testing.T) {
+	fingerprint := ComputeFingerprint("POST", "/rides", []byte(`{"a":1}`))
+	k := &Key{RequestFingerprint: fingerprint}
+
+	t.Run("matching fingerprint is not a conflict", func(t *testing.T) {
+		assert.NoError(t, CheckConflict(k, fingerprint))
+	})
+
+	t.Run("mismatched fingerprint is ErrKeyConflict", func(t *testing.T) {
+		other := ComputeFingerprint("POST", "/rides", []byte(`{"a":2}`))
+		assert.ErrorIs(t, CheckConflict(k, other), ErrKeyConflict)
+	})
+}
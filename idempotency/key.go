@@ -0,0 +1,90 @@
+// Package idempotency implements Stripe-style idempotency keys: every
+// mutating request can be retried safely by replaying the same
+// Idempotency-Key header, and the recovery point on the stored key lets a
+// multi-step handler (e.g. create ride, then charge) resume exactly where a
+// prior attempt left off instead of re-running side effects.
+//
+// This package holds the backend-agnostic pieces: the Key record, the
+// recovery-point state machine, and the Store interface. Concrete storage
+// backends live in subpackages (pgstore, memstore, redisstore).
+package idempotency
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RecoveryPoint marks how far a request handler got before it returned (or
+// crashed). Handlers advance it via Store.Update between side-effecting
+// steps so a retry can skip the work that already happened.
+type RecoveryPoint string
+
+const (
+	StartedRecoveryPoint       RecoveryPoint = "started"
+	RideCreatedRecoveryPoint   RecoveryPoint = "ride_created"
+	ChargeCreatedRecoveryPoint RecoveryPoint = "charge_created"
+	FinishedRecoveryPoint      RecoveryPoint = "finished"
+)
+
+// DefaultTTL is how long an idempotency key is honored after creation before
+// it's treated as expired and eligible for reaping. Stripe-style APIs keep
+// keys around for about a day; that's long enough to cover client retry
+// backoff without keeping the request/response bodies forever.
+const DefaultTTL = 24 * time.Hour
+
+// ErrSQLNoRows is returned by Store.Find when no key row exists for the
+// given user and key (including keys that exist but have expired). The name
+// predates the Store interface, back when callers hit Postgres directly;
+// every backend still returns it for a missing key.
+var ErrSQLNoRows = errors.New("idempotency: no rows found")
+
+// Key is a single idempotency key record: the request that created it, the
+// response it produced (once finished), and enough bookkeeping to detect
+// concurrent replays and expire stale rows.
+type Key struct {
+	ID        int64
+	CreatedAt time.Time
+	Key       string
+
+	// ExpiresAt is when this key stops being honored. Once passed, Find
+	// behaves as though the row doesn't exist, so a replay re-executes the
+	// request instead of returning a stale response.
+	ExpiresAt time.Time
+
+	LastRunAt time.Time
+	LockedAt  sql.Null[time.Time]
+
+	// LockToken is the token the current lock holder (if any) must present to
+	// ReleaseLock. It's reminted on every AcquireLock so a crashed worker can
+	// never release a lock it no longer holds.
+	LockToken sql.Null[string]
+
+	RequestMethod string
+	RequestParams []byte
+	RequestPath   string
+
+	// RequestFingerprint is a SHA-256 hash over the canonicalized method,
+	// path, and body of the request that created this key. It's compared
+	// against replays to detect a key being reused with a different request.
+	RequestFingerprint []byte
+
+	ResponseCode sql.Null[int]
+	ResponseBody sql.Null[[]byte]
+
+	RecoveryPoint RecoveryPoint
+	UserID        int
+}
+
+// KeyParams are the fields needed to start tracking a new idempotency key.
+// Everything else (timestamps, recovery point) is filled in by Store.Insert.
+type KeyParams struct {
+	Key           string
+	RequestMethod string
+	RequestParams []byte
+	RequestPath   string
+	UserID        int
+
+	// TTL overrides DefaultTTL for this key, if nonzero.
+	TTL time.Duration
+}
@@ -0,0 +1,9 @@
+package idempotency
+
+import "errors"
+
+// ErrLockNotHeld is returned by Store.ReleaseLock when the caller's lock
+// token doesn't match the one currently recorded on the key, meaning either
+// the lock expired and was stolen by another worker, or the caller never
+// held it in the first place.
+var ErrLockNotHeld = errors.New("idempotency: lock not held")
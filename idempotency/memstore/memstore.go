@@ -0,0 +1,175 @@
+// Package memstore is an in-memory idempotency.Store, useful for tests and
+// single-process deployments that don't want a Postgres dependency.
+package memstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/google/uuid"
+)
+
+type recordID struct {
+	userID int
+	key    string
+}
+
+// Store is a map-backed idempotency.Store guarded by a single mutex. It's
+// not meant to scale past one process, but it gives tests the same
+// semantics as pgstore without a database.
+type Store struct {
+	mu      sync.Mutex
+	records map[recordID]*idempotency.Key
+	nextID  int64
+}
+
+// New returns an empty Store.
+func New() *Store {
+	return &Store{records: make(map[recordID]*idempotency.Key)}
+}
+
+var _ idempotency.Store = (*Store)(nil)
+
+func (s *Store) Find(_ context.Context, userID int, key string) (*idempotency.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.records[recordID{userID, key}]
+	if !ok || time.Now().After(k.ExpiresAt) {
+		return nil, idempotency.ErrSQLNoRows
+	}
+
+	cp := *k
+	return &cp, nil
+}
+
+// Insert creates a new idempotency key. If one already exists for the same
+// (UserID, Key) and hasn't expired, that existing key is returned instead
+// of overwriting it, matching pgstore's ON CONFLICT ... WHERE expires_at <
+// now() semantics: a still-live key wins the race, an expired one is reset.
+func (s *Store) Insert(_ context.Context, params idempotency.KeyParams) (*idempotency.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := recordID{params.UserID, params.Key}
+	if existing, ok := s.records[id]; ok && time.Now().Before(existing.ExpiresAt) {
+		cp := *existing
+		return &cp, nil
+	}
+
+	ttl := params.TTL
+	if ttl <= 0 {
+		ttl = idempotency.DefaultTTL
+	}
+
+	now := time.Now()
+	s.nextID++
+
+	k := &idempotency.Key{
+		ID:                 s.nextID,
+		CreatedAt:          now,
+		Key:                params.Key,
+		ExpiresAt:          now.Add(ttl),
+		LastRunAt:          now,
+		RequestMethod:      params.RequestMethod,
+		RequestParams:      params.RequestParams,
+		RequestPath:        params.RequestPath,
+		RequestFingerprint: idempotency.ComputeFingerprint(params.RequestMethod, params.RequestPath, params.RequestParams),
+		RecoveryPoint:      idempotency.StartedRecoveryPoint,
+		UserID:             params.UserID,
+	}
+	s.records[id] = k
+
+	cp := *k
+	return &cp, nil
+}
+
+func (s *Store) Update(_ context.Context, key *idempotency.Key) (*idempotency.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.records[recordID{key.UserID, key.Key}]
+	if !ok {
+		return nil, idempotency.ErrSQLNoRows
+	}
+
+	existing.LastRunAt = time.Now()
+	existing.LockedAt = key.LockedAt
+	existing.ResponseCode = key.ResponseCode
+	existing.ResponseBody = key.ResponseBody
+	existing.RecoveryPoint = key.RecoveryPoint
+
+	cp := *existing
+	return &cp, nil
+}
+
+func (s *Store) AcquireLock(_ context.Context, userID int, key string, ttl time.Duration) (*idempotency.Key, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.records[recordID{userID, key}]
+	if !ok {
+		return nil, "", idempotency.ErrLockNotHeld
+	}
+
+	if existing.LockedAt.Valid && time.Since(existing.LockedAt.V) < ttl {
+		return nil, "", idempotency.ErrLockNotHeld
+	}
+
+	lockToken := uuid.NewString()
+	existing.LockedAt.V, existing.LockedAt.Valid = time.Now(), true
+	existing.LockToken.V, existing.LockToken.Valid = lockToken, true
+
+	cp := *existing
+	return &cp, lockToken, nil
+}
+
+func (s *Store) ReleaseLock(_ context.Context, key *idempotency.Key, lockToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.records[recordID{key.UserID, key.Key}]
+	if !ok || !existing.LockToken.Valid || existing.LockToken.V != lockToken {
+		return idempotency.ErrLockNotHeld
+	}
+
+	existing.LockedAt.Valid = false
+	existing.LockToken.Valid = false
+	return nil
+}
+
+func (s *Store) ExtendLock(_ context.Context, key *idempotency.Key, lockToken string, _ time.Duration) (*idempotency.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.records[recordID{key.UserID, key.Key}]
+	if !ok || !existing.LockToken.Valid || existing.LockToken.V != lockToken {
+		return nil, idempotency.ErrLockNotHeld
+	}
+
+	existing.LockedAt.V, existing.LockedAt.Valid = time.Now(), true
+
+	cp := *existing
+	return &cp, nil
+}
+
+func (s *Store) Reap(_ context.Context, batchSize int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	reaped := 0
+	for id, k := range s.records {
+		if reaped >= batchSize {
+			break
+		}
+		if k.LockedAt.Valid || now.Before(k.ExpiresAt) {
+			continue
+		}
+		delete(s.records, id)
+		reaped++
+	}
+	return reaped, nil
+}
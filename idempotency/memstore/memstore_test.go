@@ -0,0 +1,15 @@
+package memstore_test
+
+import (
+	"testing"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/anmho/idempotent-rides/idempotency/memstore"
+	"github.com/anmho/idempotent-rides/idempotency/storetest"
+)
+
+func TestStore_Conformance(t *testing.T) {
+	storetest.Run(t, func() idempotency.Store {
+		return memstore.New()
+	})
+}
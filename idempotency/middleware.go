@@ -0,0 +1,196 @@
+package idempotency
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultHeaderName is the request header Middleware reads the idempotency
+// key from, matching Stripe's convention.
+const DefaultHeaderName = "Idempotency-Key"
+
+// DefaultLockTTL bounds how long Middleware holds the lock on a key while
+// the wrapped handler runs, so a crashed handler doesn't pin the lock
+// forever.
+const DefaultLockTTL = 30 * time.Second
+
+// Options configures Middleware.
+type Options struct {
+	// HeaderName is the request header carrying the idempotency key.
+	// Defaults to DefaultHeaderName.
+	HeaderName string
+
+	// LockTTL bounds how long a lock is held for a single request. Defaults
+	// to DefaultLockTTL.
+	LockTTL time.Duration
+
+	// UserID extracts the acting user from the request, so keys can be
+	// scoped per user the way Stripe scopes them per API key. Required.
+	UserID func(*http.Request) (int, error)
+
+	// OnError is called whenever Middleware itself fails open a response
+	// other than the handler's (lock contention, key conflict, storage
+	// errors). Defaults to writing the same {"error": {"code": ..., ...}}
+	// envelope send.WriteError(w, send.FromErr(err)) would, so callers get a
+	// machine-readable Code without wiring anything up themselves.
+	OnError func(w http.ResponseWriter, r *http.Request, status int, err error)
+}
+
+func (o *Options) setDefaults() {
+	if o.HeaderName == "" {
+		o.HeaderName = DefaultHeaderName
+	}
+	if o.LockTTL <= 0 {
+		o.LockTTL = DefaultLockTTL
+	}
+	if o.OnError == nil {
+		o.OnError = func(w http.ResponseWriter, _ *http.Request, status int, err error) {
+			writeError(w, status, ErrorFor(err))
+		}
+	}
+}
+
+// Middleware wraps next so that any request carrying the idempotency key
+// header is automatically replay-safe: a first attempt runs next and its
+// response is captured and stored; a retry with the same key and request
+// body gets that stored response played back without next running again. A
+// retry with the same key but a different body is rejected as a conflict
+// rather than either one being silently served.
+//
+// Handlers that want to record progress between side-effecting steps call
+// SetRecoveryPoint with the context Middleware passes to next; because the
+// ResponseWriter next receives is just a recording http.ResponseWriter, any
+// existing send.WriteJSON call in the handler keeps working unchanged and
+// its payload is captured for free.
+func Middleware(store Store, opts Options) func(http.Handler) http.Handler {
+	if opts.UserID == nil {
+		panic("idempotency: Middleware: Options.UserID is required")
+	}
+	opts.setDefaults()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(opts.HeaderName)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID, err := opts.UserID(r)
+			if err != nil {
+				opts.OnError(w, r, http.StatusUnauthorized, err)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				opts.OnError(w, r, http.StatusBadRequest, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := ComputeFingerprint(r.Method, r.URL.Path, body)
+
+			ctx := r.Context()
+
+			existing, err := store.Find(ctx, userID, key)
+			switch {
+			case errors.Is(err, ErrSQLNoRows):
+				existing, err = store.Insert(ctx, KeyParams{
+					Key:           key,
+					RequestMethod: r.Method,
+					RequestParams: body,
+					RequestPath:   r.URL.Path,
+					UserID:        userID,
+				})
+				if err != nil {
+					opts.OnError(w, r, http.StatusInternalServerError, err)
+					return
+				}
+			case err != nil:
+				opts.OnError(w, r, http.StatusInternalServerError, err)
+				return
+			default:
+				if err := CheckConflict(existing, fingerprint); err != nil {
+					opts.OnError(w, r, http.StatusUnprocessableEntity, err)
+					return
+				}
+				if existing.RecoveryPoint == FinishedRecoveryPoint && existing.ResponseCode.Valid {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(existing.ResponseCode.V)
+					_, _ = w.Write(existing.ResponseBody.V)
+					return
+				}
+			}
+
+			locked, lockToken, err := store.AcquireLock(ctx, userID, key, opts.LockTTL)
+			if errors.Is(err, ErrLockNotHeld) {
+				opts.OnError(w, r, http.StatusConflict, err)
+				return
+			}
+			if err != nil {
+				opts.OnError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+
+			rec := newResponseRecorder(w)
+			next.ServeHTTP(rec, r.WithContext(withKey(ctx, store, locked)))
+			rec.flush()
+
+			locked.ResponseCode = sql.Null[int]{V: rec.status, Valid: true}
+			locked.ResponseBody = sql.Null[[]byte]{V: rec.body.Bytes(), Valid: true}
+			locked.RecoveryPoint = FinishedRecoveryPoint
+			if _, err := store.Update(ctx, locked); err != nil {
+				opts.OnError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+
+			if err := store.ReleaseLock(ctx, locked, lockToken); err != nil {
+				opts.OnError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+		})
+	}
+}
+
+// responseRecorder buffers a handler's response so it can be persisted to
+// the Store after the handler returns, while still forwarding bytes to the
+// real ResponseWriter as they're written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// flush ensures a handler that wrote neither a header nor a body still has
+// its implicit 200 status committed.
+func (rec *responseRecorder) flush() {
+	if !rec.wroteHeader {
+		rec.WriteHeader(rec.status)
+	}
+}
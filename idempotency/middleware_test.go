@@ -0,0 +1,151 @@
+package idempotency_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/anmho/idempotent-rides/idempotency/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUserID = 1
+
+func testOptions() idempotency.Options {
+	return idempotency.Options{
+		UserID: func(*http.Request) (int, error) { return testUserID, nil },
+	}
+}
+
+func TestMiddleware_RunsHandlerOnce(t *testing.T) {
+	store := memstore.New()
+	var calls atomic.Int32
+
+	handler := idempotency.Middleware(store, testOptions())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+
+	req := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(`{"a":1}`))
+		r.Header.Set(idempotency.DefaultHeaderName, "key-1")
+		return r
+	}
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, req())
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, `{"ok":true}`, first.Body.String())
+
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, req())
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, `{"ok":true}`, second.Body.String(), "replay must return the original response")
+
+	assert.Equal(t, int32(1), calls.Load(), "handler must not run twice for the same key")
+}
+
+func TestMiddleware_RejectsKeyReuseWithDifferentBody(t *testing.T) {
+	store := memstore.New()
+	handler := idempotency.Middleware(store, testOptions())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	first := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(`{"a":1}`))
+	first.Header.Set(idempotency.DefaultHeaderName, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), first)
+
+	second := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(`{"a":2}`))
+	second.Header.Set(idempotency.DefaultHeaderName, "key-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, second)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestMiddleware_RejectsConcurrentRequestsWithLockContention(t *testing.T) {
+	store := memstore.New()
+	release := make(chan struct{})
+	entered := make(chan struct{})
+
+	handler := idempotency.Middleware(store, testOptions())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(`{"a":1}`))
+		r.Header.Set(idempotency.DefaultHeaderName, "key-1")
+		return r
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), req())
+	}()
+
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("handler never started")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req())
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	close(release)
+	wg.Wait()
+}
+
+func TestMiddleware_SkipsKeysWithoutHeader(t *testing.T) {
+	store := memstore.New()
+	var calls atomic.Int32
+
+	handler := idempotency.Middleware(store, testOptions())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(`{}`))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, int32(2), calls.Load(), "requests without the header must not be deduplicated")
+}
+
+func TestMiddleware_PersistsResponseAndReleasesLock(t *testing.T) {
+	store := memstore.New()
+	handler := idempotency.Middleware(store, testOptions())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(`{"a":1}`))
+	r.Header.Set(idempotency.DefaultHeaderName, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	k, err := store.Find(r.Context(), testUserID, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, idempotency.FinishedRecoveryPoint, k.RecoveryPoint)
+	assert.Equal(t, http.StatusCreated, k.ResponseCode.V)
+	assert.Equal(t, `{"ok":true}`, string(k.ResponseBody.V))
+	assert.False(t, k.LockedAt.Valid, "lock must be released once the handler finishes")
+}
+
+func TestMiddleware_PanicsWithoutUserID(t *testing.T) {
+	store := memstore.New()
+	assert.Panics(t, func() {
+		idempotency.Middleware(store, idempotency.Options{})
+	})
+}
@@ -0,0 +1,209 @@
+// Package pgstore is the Postgres implementation of idempotency.Store.
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/google/uuid"
+)
+
+// Store persists idempotency keys in a Postgres idempotency_keys table (see
+// schema.sql). Every method is a single atomic statement, except that Insert
+// follows up with a separate Find when it loses a race for a still-live key,
+// so callers don't need to manage transactions across Store calls themselves.
+type Store struct {
+	DB *sql.DB
+}
+
+// New returns a Store backed by db.
+func New(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+var _ idempotency.Store = (*Store)(nil)
+
+const selectColumns = `
+	id, created_at, idempotency_key, expires_at, last_run_at, locked_at, lock_token,
+	request_method, request_params, request_path, request_fingerprint,
+	response_code, response_body, recovery_point, user_id
+`
+
+func (s *Store) Find(ctx context.Context, userID int, key string) (*idempotency.Key, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT `+selectColumns+`
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND expires_at > now()
+	`, userID, key)
+
+	k, err := scanKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, idempotency.ErrSQLNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pgstore: finding key: %w", err)
+	}
+	return k, nil
+}
+
+// Insert creates a new idempotency key, or, if a key with the same
+// (user_id, idempotency_key) already exists but has expired and simply
+// hasn't been reaped yet, resets that row as if it were brand new. Without
+// this, a retry after expiry would hit the UNIQUE (user_id, idempotency_key)
+// constraint and fail with a generic conflict instead of being re-executed.
+//
+// If the existing row hasn't expired, the conflict is left alone (a race
+// with a concurrent Insert of the same still-live key) and the winning row
+// is returned instead.
+func (s *Store) Insert(ctx context.Context, params idempotency.KeyParams) (*idempotency.Key, error) {
+	ttl := params.TTL
+	if ttl <= 0 {
+		ttl = idempotency.DefaultTTL
+	}
+
+	fingerprint := idempotency.ComputeFingerprint(params.RequestMethod, params.RequestPath, params.RequestParams)
+
+	row := s.DB.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys
+			(idempotency_key, created_at, expires_at, last_run_at,
+			 request_method, request_params, request_path, request_fingerprint,
+			 recovery_point, user_id)
+		VALUES ($1, now(), now() + $2::interval, now(), $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET
+			created_at          = now(),
+			expires_at          = now() + $2::interval,
+			last_run_at         = now(),
+			locked_at           = NULL,
+			lock_token          = NULL,
+			request_method      = EXCLUDED.request_method,
+			request_params      = EXCLUDED.request_params,
+			request_path        = EXCLUDED.request_path,
+			request_fingerprint = EXCLUDED.request_fingerprint,
+			response_code       = NULL,
+			response_body       = NULL,
+			recovery_point      = EXCLUDED.recovery_point
+		WHERE idempotency_keys.expires_at < now()
+		RETURNING `+selectColumns, params.Key, ttl.String(), params.RequestMethod, params.RequestParams,
+		params.RequestPath, fingerprint, idempotency.StartedRecoveryPoint, params.UserID)
+
+	k, err := scanKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.Find(ctx, params.UserID, params.Key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pgstore: inserting key: %w", err)
+	}
+	return k, nil
+}
+
+func (s *Store) Update(ctx context.Context, key *idempotency.Key) (*idempotency.Key, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		UPDATE idempotency_keys
+		SET last_run_at = now(),
+		    locked_at = $2,
+		    response_code = $3,
+		    response_body = $4,
+		    recovery_point = $5
+		WHERE id = $1
+		RETURNING `+selectColumns, key.ID, key.LockedAt, key.ResponseCode, key.ResponseBody, key.RecoveryPoint)
+
+	k, err := scanKey(row)
+	if err != nil {
+		return nil, fmt.Errorf("pgstore: updating key: %w", err)
+	}
+	return k, nil
+}
+
+func (s *Store) AcquireLock(ctx context.Context, userID int, key string, ttl time.Duration) (*idempotency.Key, string, error) {
+	lockToken := uuid.NewString()
+
+	row := s.DB.QueryRowContext(ctx, `
+		UPDATE idempotency_keys
+		SET locked_at = now(), lock_token = $4
+		WHERE user_id = $1 AND idempotency_key = $2
+		  AND (locked_at IS NULL OR locked_at < now() - $3::interval)
+		RETURNING `+selectColumns, userID, key, ttl.String(), lockToken)
+
+	k, err := scanKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", idempotency.ErrLockNotHeld
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("pgstore: acquiring lock: %w", err)
+	}
+	return k, lockToken, nil
+}
+
+func (s *Store) ReleaseLock(ctx context.Context, key *idempotency.Key, lockToken string) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET locked_at = NULL, lock_token = NULL
+		WHERE id = $1 AND lock_token = $2
+	`, key.ID, lockToken)
+	if err != nil {
+		return fmt.Errorf("pgstore: releasing lock: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("pgstore: counting affected rows: %w", err)
+	}
+	if n == 0 {
+		return idempotency.ErrLockNotHeld
+	}
+	return nil
+}
+
+func (s *Store) ExtendLock(ctx context.Context, key *idempotency.Key, lockToken string, ttl time.Duration) (*idempotency.Key, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		UPDATE idempotency_keys
+		SET locked_at = now()
+		WHERE id = $1 AND lock_token = $2
+		RETURNING `+selectColumns, key.ID, lockToken)
+
+	k, err := scanKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, idempotency.ErrLockNotHeld
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pgstore: extending lock: %w", err)
+	}
+	return k, nil
+}
+
+func (s *Store) Reap(ctx context.Context, batchSize int) (int, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE id IN (
+			SELECT id FROM idempotency_keys
+			WHERE expires_at < now() AND locked_at IS NULL
+			LIMIT $1
+		)
+	`, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("pgstore: reaping expired keys: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("pgstore: counting reaped keys: %w", err)
+	}
+	return int(n), nil
+}
+
+func scanKey(row *sql.Row) (*idempotency.Key, error) {
+	var k idempotency.Key
+	err := row.Scan(
+		&k.ID, &k.CreatedAt, &k.Key, &k.ExpiresAt, &k.LastRunAt, &k.LockedAt, &k.LockToken,
+		&k.RequestMethod, &k.RequestParams, &k.RequestPath, &k.RequestFingerprint,
+		&k.ResponseCode, &k.ResponseBody, &k.RecoveryPoint, &k.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
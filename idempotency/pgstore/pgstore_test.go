@@ -0,0 +1,213 @@
+package pgstore_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/anmho/idempotent-rides/idempotency/pgstore"
+	"github.com/anmho/idempotent-rides/idempotency/storetest"
+	"github.com/anmho/idempotent-rides/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const TestUserID = 123
+
+func TestStore_Conformance(t *testing.T) {
+	storetest.Run(t, func() idempotency.Store {
+		return pgstore.New(test.MakePostgres(t))
+	})
+}
+
+func assertEqualIdempotencyKey(t *testing.T, expectedIdempotencyKey, idempotencyKey *idempotency.Key) {
+	assert.Equal(t, expectedIdempotencyKey.Key, idempotencyKey.Key, "key strings")
+	assert.Equal(t, expectedIdempotencyKey.UserID, idempotencyKey.UserID, "UserID")
+
+	assert.Equal(t, expectedIdempotencyKey.RequestMethod, idempotencyKey.RequestMethod, "http method")
+	assert.Equal(t, expectedIdempotencyKey.RequestPath, idempotencyKey.RequestPath, "request path")
+	assert.Equal(t, expectedIdempotencyKey.RequestParams, idempotencyKey.RequestParams, "request params")
+
+	assert.Equal(t, expectedIdempotencyKey.ResponseCode, idempotencyKey.ResponseCode, "send code")
+	assert.Equal(t, expectedIdempotencyKey.ResponseBody, idempotencyKey.ResponseBody, "send body")
+	assert.Equal(t, expectedIdempotencyKey.RecoveryPoint, idempotencyKey.RecoveryPoint, "recovery point")
+}
+
+func Test_Find(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := test.MakePostgres(t)
+	store := pgstore.New(db)
+
+	inserted, err := store.Insert(ctx, idempotency.KeyParams{
+		Key:           "testKeyFinished",
+		RequestMethod: http.MethodPost,
+		RequestParams: []byte("{}"),
+		RequestPath:   "/rides",
+		UserID:        TestUserID,
+	})
+	require.NoError(t, err)
+
+	inserted.ResponseCode = sql.Null[int]{V: 201, Valid: true}
+	inserted.ResponseBody = sql.Null[[]byte]{V: []byte("{}"), Valid: true}
+	inserted.RecoveryPoint = idempotency.FinishedRecoveryPoint
+	_, err = store.Update(ctx, inserted)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  string
+
+		expectedErr            bool
+		expectedIdempotencyKey *idempotency.Key
+	}{
+		{
+			name: "happy path: full idempotency key is present",
+			key:  "testKeyFinished",
+
+			expectedErr: false,
+			expectedIdempotencyKey: &idempotency.Key{
+				Key:           "testKeyFinished",
+				RequestMethod: http.MethodPost,
+				RequestParams: []byte("{}"),
+				RequestPath:   "/rides",
+				ResponseCode: sql.Null[int]{
+					V:     201,
+					Valid: true,
+				},
+				ResponseBody: sql.Null[[]byte]{
+					V:     []byte("{}"),
+					Valid: true,
+				},
+				RecoveryPoint: idempotency.FinishedRecoveryPoint,
+				UserID:        TestUserID,
+			},
+		},
+		{
+			name: "error path: user exists but associated idempotency key is not in the database. should error ErrSQLNoRows",
+			key:  "keyThatDoesntExist",
+
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			idempotencyKey, err := store.Find(ctx, TestUserID, tc.key)
+			if tc.expectedErr {
+				assert.ErrorIs(t, err, idempotency.ErrSQLNoRows)
+			} else {
+				require.NoError(t, err)
+				assertEqualIdempotencyKey(t, tc.expectedIdempotencyKey, idempotencyKey)
+			}
+		})
+	}
+}
+
+func Test_Insert(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params idempotency.KeyParams
+
+		expectedIdempotencyKey *idempotency.Key
+	}{
+		{
+			name: "happy path: insert new idempotency key with valid fields and empty body",
+			params: idempotency.KeyParams{
+				Key:           "awesomeKey",
+				RequestMethod: http.MethodPost,
+				RequestParams: []byte("{}"),
+				RequestPath:   "/charges",
+				UserID:        TestUserID,
+			},
+
+			// We will assume timestamps will work since they are harder to mock but we should find a way.
+			expectedIdempotencyKey: &idempotency.Key{
+				Key:           "awesomeKey",
+				RequestMethod: http.MethodPost,
+				RequestParams: []byte("{}"),
+				RequestPath:   "/charges",
+				ResponseBody:  sql.Null[[]byte]{},
+				ResponseCode:  sql.Null[int]{},
+				RecoveryPoint: idempotency.StartedRecoveryPoint,
+				UserID:        TestUserID,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db := test.MakePostgres(t)
+			store := pgstore.New(db)
+
+			ctx := context.Background()
+			idempotencyKey, err := store.Insert(ctx, tc.params)
+			require.NoError(t, err)
+			require.NotNil(t, idempotencyKey, "idempotency not nil")
+
+			// skip timestamps since that would be difficult to mock
+			assertEqualIdempotencyKey(t, tc.expectedIdempotencyKey, idempotencyKey)
+			assert.WithinDuration(t, time.Now(), idempotencyKey.ExpiresAt, idempotency.DefaultTTL+time.Minute)
+		})
+	}
+}
+
+func Test_Update(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := test.MakePostgres(t)
+	store := pgstore.New(db)
+
+	inserted, err := store.Insert(ctx, idempotency.KeyParams{
+		Key:           "testKeyRideCreated",
+		RequestMethod: http.MethodPost,
+		RequestParams: []byte("{}"),
+		RequestPath:   "/rides",
+		UserID:        TestUserID,
+	})
+	require.NoError(t, err)
+
+	inserted.RecoveryPoint = idempotency.ChargeCreatedRecoveryPoint
+	updatedKey, err := store.Update(ctx, inserted)
+	require.NoError(t, err)
+	require.NotNil(t, updatedKey)
+
+	assert.Equal(t, idempotency.ChargeCreatedRecoveryPoint, updatedKey.RecoveryPoint, "recovery point")
+}
+
+func Test_AcquireAndReleaseLock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := test.MakePostgres(t)
+	store := pgstore.New(db)
+
+	_, err := store.Insert(ctx, idempotency.KeyParams{
+		Key:           "testKeyLocked",
+		RequestMethod: http.MethodPost,
+		RequestParams: []byte("{}"),
+		RequestPath:   "/rides",
+		UserID:        TestUserID,
+	})
+	require.NoError(t, err)
+
+	locked, lockToken, err := store.AcquireLock(ctx, TestUserID, "testKeyLocked", time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, lockToken)
+
+	_, _, err = store.AcquireLock(ctx, TestUserID, "testKeyLocked", time.Minute)
+	assert.ErrorIs(t, err, idempotency.ErrLockNotHeld, "locked key cannot be reacquired before its ttl elapses")
+
+	err = store.ReleaseLock(ctx, locked, "wrong-token")
+	assert.ErrorIs(t, err, idempotency.ErrLockNotHeld, "releasing with the wrong token is rejected")
+
+	err = store.ReleaseLock(ctx, locked, lockToken)
+	assert.NoError(t, err)
+}
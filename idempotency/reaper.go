@@ -0,0 +1,103 @@
+package idempotency
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// reapBatchSize bounds how many rows a single Reap call removes, so the
+// reaper never holds a long-running lock on the underlying store.
+const reapBatchSize = 1000
+
+// ReaperMetrics lets callers observe reaper activity (e.g. wire up
+// Prometheus counters) without the idempotency package taking a metrics
+// dependency itself.
+type ReaperMetrics interface {
+	ObserveReaped(count int)
+	ObserveError(err error)
+}
+
+// noopMetrics is used when Reaper is constructed without metrics.
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveReaped(int)  {}
+func (noopMetrics) ObserveError(error) {}
+
+// Reaper periodically deletes expired, unlocked idempotency keys from a
+// Store so it doesn't grow without bound. Locked rows are left alone even if
+// expired, since a handler may still be mid-flight and relying on the row.
+type Reaper struct {
+	Store   Store
+	Metrics ReaperMetrics
+	Logger  *log.Logger
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReaper returns a Reaper that deletes expired keys from store.
+func NewReaper(store Store) *Reaper {
+	return &Reaper{Store: store}
+}
+
+// Start runs the reap loop in a background goroutine, deleting expired keys
+// in batches (see reapOnce) every interval until Stop is called or ctx is
+// done.
+func (r *Reaper) Start(ctx context.Context, interval time.Duration) {
+	if r.Metrics == nil {
+		r.Metrics = noopMetrics{}
+	}
+	if r.Logger == nil {
+		r.Logger = log.Default()
+	}
+
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.reapOnce(ctx)
+			}
+		}
+	}()
+}
+
+// Stop signals the reap loop to exit and blocks until it has.
+func (r *Reaper) Stop() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+}
+
+// reapOnce deletes expired, unlocked keys in batches until a batch comes
+// back short, so a backlog of expired rows doesn't require waiting for
+// interval to tick once per batch.
+func (r *Reaper) reapOnce(ctx context.Context) {
+	for {
+		n, err := r.Store.Reap(ctx, reapBatchSize)
+		if err != nil {
+			r.Metrics.ObserveError(err)
+			r.Logger.Printf("idempotency: reap batch failed: %v", err)
+			return
+		}
+
+		r.Metrics.ObserveReaped(n)
+		if n < reapBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Package redisstore is a Redis implementation of idempotency.Store, for
+// deployments that want idempotency keys shared across processes without
+// standing up Postgres.
+package redisstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store persists each idempotency key as a JSON string value under its own
+// Redis key (plain SET/GET; Redis hashes are not used) and locks as separate
+// Redis keys with a native TTL, using SET NX PX for the atomic
+// compare-and-swap that Postgres gets from AcquireLock's UPDATE ... RETURNING.
+type Store struct {
+	Client *redis.Client
+}
+
+// New returns a Store backed by client.
+func New(client *redis.Client) *Store {
+	return &Store{Client: client}
+}
+
+var _ idempotency.Store = (*Store)(nil)
+
+func recordKey(userID int, key string) string {
+	return fmt.Sprintf("idempotency:key:%d:%s", userID, key)
+}
+
+func lockKey(userID int, key string) string {
+	return fmt.Sprintf("idempotency:lock:%d:%s", userID, key)
+}
+
+// record is the JSON-serializable form of idempotency.Key, stored as the
+// string value of the key's recordKey. A single JSON blob (rather than one
+// field per column) keeps Update and Find to one Redis round trip each;
+// Insert takes two (INCR for the ID, then SET NX).
+type record struct {
+	ID                 int64
+	CreatedAt          time.Time
+	Key                string
+	ExpiresAt          time.Time
+	LastRunAt          time.Time
+	LockedAt           *time.Time
+	LockToken          *string
+	RequestMethod      string
+	RequestParams      []byte
+	RequestPath        string
+	RequestFingerprint []byte
+	ResponseCode       *int
+	ResponseBody       []byte
+	RecoveryPoint      idempotency.RecoveryPoint
+	UserID             int
+}
+
+func toRecord(k *idempotency.Key) record {
+	r := record{
+		ID:                 k.ID,
+		CreatedAt:          k.CreatedAt,
+		Key:                k.Key,
+		ExpiresAt:          k.ExpiresAt,
+		LastRunAt:          k.LastRunAt,
+		RequestMethod:      k.RequestMethod,
+		RequestParams:      k.RequestParams,
+		RequestPath:        k.RequestPath,
+		RequestFingerprint: k.RequestFingerprint,
+		ResponseBody:       k.ResponseBody.V,
+		RecoveryPoint:      k.RecoveryPoint,
+		UserID:             k.UserID,
+	}
+	if k.LockedAt.Valid {
+		r.LockedAt = &k.LockedAt.V
+	}
+	if k.LockToken.Valid {
+		r.LockToken = &k.LockToken.V
+	}
+	if k.ResponseCode.Valid {
+		r.ResponseCode = &k.ResponseCode.V
+	}
+	return r
+}
+
+func (r record) toKey() *idempotency.Key {
+	k := &idempotency.Key{
+		ID:                 r.ID,
+		CreatedAt:          r.CreatedAt,
+		Key:                r.Key,
+		ExpiresAt:          r.ExpiresAt,
+		LastRunAt:          r.LastRunAt,
+		RequestMethod:      r.RequestMethod,
+		RequestParams:      r.RequestParams,
+		RequestPath:        r.RequestPath,
+		RequestFingerprint: r.RequestFingerprint,
+		RecoveryPoint:      r.RecoveryPoint,
+		UserID:             r.UserID,
+	}
+	if r.LockedAt != nil {
+		k.LockedAt = sql.Null[time.Time]{V: *r.LockedAt, Valid: true}
+	}
+	if r.LockToken != nil {
+		k.LockToken = sql.Null[string]{V: *r.LockToken, Valid: true}
+	}
+	if r.ResponseCode != nil {
+		k.ResponseCode = sql.Null[int]{V: *r.ResponseCode, Valid: true}
+		k.ResponseBody = sql.Null[[]byte]{V: r.ResponseBody, Valid: true}
+	}
+	return k
+}
+
+func (s *Store) Find(ctx context.Context, userID int, key string) (*idempotency.Key, error) {
+	data, err := s.Client.Get(ctx, recordKey(userID, key)).Bytes()
+	if err == redis.Nil {
+		return nil, idempotency.ErrSQLNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: finding key: %w", err)
+	}
+
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("redisstore: decoding key: %w", err)
+	}
+	if time.Now().After(r.ExpiresAt) {
+		return nil, idempotency.ErrSQLNoRows
+	}
+	return r.toKey(), nil
+}
+
+// Insert creates a new idempotency key using SET NX, the same atomic
+// compare-and-swap AcquireLock uses for locks, so two concurrent first-time
+// requests under the same key can't race a plain Find-then-Set and both
+// believe they won: only one SET NX succeeds, and the loser reads back the
+// winner's row via Find, the same "let the winner of a race be returned"
+// semantics pgstore gets from ON CONFLICT ... WHERE expires_at < now(). If
+// the row Find reads back has already expired, it is reset to the new key.
+func (s *Store) Insert(ctx context.Context, params idempotency.KeyParams) (*idempotency.Key, error) {
+	ttl := params.TTL
+	if ttl <= 0 {
+		ttl = idempotency.DefaultTTL
+	}
+
+	now := time.Now()
+	id, err := s.Client.Incr(ctx, "idempotency:next_id").Result()
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: allocating key id: %w", err)
+	}
+
+	k := &idempotency.Key{
+		ID:                 id,
+		CreatedAt:          now,
+		Key:                params.Key,
+		ExpiresAt:          now.Add(ttl),
+		LastRunAt:          now,
+		RequestMethod:      params.RequestMethod,
+		RequestParams:      params.RequestParams,
+		RequestPath:        params.RequestPath,
+		RequestFingerprint: idempotency.ComputeFingerprint(params.RequestMethod, params.RequestPath, params.RequestParams),
+		RecoveryPoint:      idempotency.StartedRecoveryPoint,
+		UserID:             params.UserID,
+	}
+
+	data, err := json.Marshal(toRecord(k))
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: encoding key: %w", err)
+	}
+
+	ok, err := s.Client.SetNX(ctx, recordKey(params.UserID, params.Key), data, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: inserting key: %w", err)
+	}
+	if !ok {
+		existing, err := s.Find(ctx, params.UserID, params.Key)
+		if !errors.Is(err, idempotency.ErrSQLNoRows) {
+			return existing, err
+		}
+		// The stored record is past its ExpiresAt but Redis hasn't evicted
+		// it yet (or it vanished after SET NX failed): reset it with a
+		// plain SET.
+		if err := s.put(ctx, params.UserID, params.Key, k, ttl); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+func (s *Store) Update(ctx context.Context, key *idempotency.Key) (*idempotency.Key, error) {
+	key.LastRunAt = time.Now()
+
+	ttl := time.Until(key.ExpiresAt)
+	if ttl <= 0 {
+		ttl = time.Second
+	}
+	if err := s.put(ctx, key.UserID, key.Key, key, ttl); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *Store) put(ctx context.Context, uid int, key string, k *idempotency.Key, ttl time.Duration) error {
+	data, err := json.Marshal(toRecord(k))
+	if err != nil {
+		return fmt.Errorf("redisstore: encoding key: %w", err)
+	}
+	if err := s.Client.Set(ctx, recordKey(uid, key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("redisstore: writing key: %w", err)
+	}
+	return nil
+}
+
+// AcquireLock uses SET NX PX on a dedicated lock key, the Redis-native
+// equivalent of the Postgres CAS update: the SET only succeeds if the lock
+// key doesn't already exist (or expired on its own via PX), which is exactly
+// the "locked_at IS NULL OR locked_at < now() - ttl" condition.
+func (s *Store) AcquireLock(ctx context.Context, uid int, key string, ttl time.Duration) (*idempotency.Key, string, error) {
+	lockToken := uuid.NewString()
+
+	ok, err := s.Client.SetNX(ctx, lockKey(uid, key), lockToken, ttl).Result()
+	if err != nil {
+		return nil, "", fmt.Errorf("redisstore: acquiring lock: %w", err)
+	}
+	if !ok {
+		return nil, "", idempotency.ErrLockNotHeld
+	}
+
+	k, err := s.Find(ctx, uid, key)
+	if err != nil {
+		// We claimed the lock but there's no record to attach it to (it
+		// expired or was never inserted). Give the lock back rather than
+		// leaving it held until its own TTL expires, and report the same
+		// ErrLockNotHeld every other failure to acquire returns.
+		_ = s.Client.Del(ctx, lockKey(uid, key)).Err()
+		if errors.Is(err, idempotency.ErrSQLNoRows) {
+			return nil, "", idempotency.ErrLockNotHeld
+		}
+		return nil, "", err
+	}
+
+	k.LockedAt = sql.Null[time.Time]{V: time.Now(), Valid: true}
+	k.LockToken = sql.Null[string]{V: lockToken, Valid: true}
+	if _, err := s.Update(ctx, k); err != nil {
+		_ = s.Client.Del(ctx, lockKey(uid, key)).Err()
+		return nil, "", err
+	}
+	return k, lockToken, nil
+}
+
+// ExtendLock pushes a held lock's expiration out by ttl, as long as
+// lockToken matches the token currently recorded. It's the Redis
+// equivalent of resetting the lock key's PX.
+func (s *Store) ExtendLock(ctx context.Context, key *idempotency.Key, lockToken string, ttl time.Duration) (*idempotency.Key, error) {
+	ok, err := extendScript.Run(ctx, s.Client, []string{lockKey(key.UserID, key.Key)}, lockToken, ttl.Milliseconds()).Bool()
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: extending lock: %w", err)
+	}
+	if !ok {
+		return nil, idempotency.ErrLockNotHeld
+	}
+	return s.Find(ctx, key.UserID, key.Key)
+}
+
+// extendScript resets the lock key's TTL only if it still holds lockToken,
+// atomically, the same check-then-act shape as releaseScript.
+var extendScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+	return 0
+`)
+
+// ReleaseLock deletes the lock key only if it still holds lockToken,
+// implemented as a Lua script so the check-and-delete is atomic.
+var releaseScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+func (s *Store) ReleaseLock(ctx context.Context, key *idempotency.Key, lockToken string) error {
+	n, err := releaseScript.Run(ctx, s.Client, []string{lockKey(key.UserID, key.Key)}, lockToken).Int()
+	if err != nil {
+		return fmt.Errorf("redisstore: releasing lock: %w", err)
+	}
+	if n == 0 {
+		return idempotency.ErrLockNotHeld
+	}
+
+	key.LockedAt = sql.Null[time.Time]{}
+	key.LockToken = sql.Null[string]{}
+	_, err = s.Update(ctx, key)
+	return err
+}
+
+// Reap is a no-op: Redis expires key records and locks natively via PX/EX,
+// so there's no unbounded table growth to clean up in batches.
+func (s *Store) Reap(context.Context, int) (int, error) {
+	return 0, nil
+}
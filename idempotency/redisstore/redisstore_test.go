@@ -0,0 +1,22 @@
+package redisstore_test
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/anmho/idempotent-rides/idempotency/redisstore"
+	"github.com/anmho/idempotent-rides/idempotency/storetest"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStore_Conformance(t *testing.T) {
+	storetest.Run(t, func() idempotency.Store {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		return redisstore.New(client)
+	})
+}
@@ -0,0 +1,45 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// Store persists idempotency keys and the locks guarding them. The core
+// package implements the recovery-point state machine on top of Store, so
+// swapping backends (Postgres, an in-memory map for tests, Redis) never
+// requires touching handler logic.
+type Store interface {
+	// Find returns the key for (userID, key), or ErrSQLNoRows if no
+	// unexpired key exists.
+	Find(ctx context.Context, userID int, key string) (*Key, error)
+
+	// Insert creates a new key in the StartedRecoveryPoint state.
+	Insert(ctx context.Context, params KeyParams) (*Key, error)
+
+	// Update persists the mutable fields of key: recovery point, response,
+	// and lock state.
+	Update(ctx context.Context, key *Key) (*Key, error)
+
+	// AcquireLock atomically claims the lock on (userID, key), minting and
+	// returning a fresh lock token. It returns ErrLockNotHeld if the key is
+	// already locked and its lock hasn't passed ttl.
+	AcquireLock(ctx context.Context, userID int, key string, ttl time.Duration) (*Key, string, error)
+
+	// ReleaseLock clears the lock on key if lockToken matches the token
+	// currently held, and returns ErrLockNotHeld otherwise.
+	ReleaseLock(ctx context.Context, key *Key, lockToken string) error
+
+	// ExtendLock renews a held lock, as long as lockToken matches the token
+	// currently recorded on the key, and returns ErrLockNotHeld otherwise.
+	// Long-running handlers call this to keep a lock alive past its original
+	// ttl without risking another worker stealing it mid-extension. How ttl
+	// applies depends on the backend: redisstore resets the lock's expiry to
+	// ttl from now, while pgstore and memstore ignore ttl and reset LockedAt
+	// to now, so the lock lasts another AcquireLock ttl from that moment.
+	ExtendLock(ctx context.Context, key *Key, lockToken string, ttl time.Duration) (*Key, error)
+
+	// Reap deletes up to batchSize expired, unlocked keys and returns how
+	// many were removed.
+	Reap(ctx context.Context, batchSize int) (int, error)
+}
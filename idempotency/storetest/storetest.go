@@ -0,0 +1,172 @@
+// Package storetest is a conformance suite shared by every idempotency.Store
+// implementation, so pgstore, memstore, and redisstore are pinned to the
+// same Insert/lock semantics instead of drifting independently.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run exercises store (freshly constructed by newStore, once per subtest)
+// against the behavior every Store implementation must share.
+func Run(t *testing.T, newStore func() idempotency.Store) {
+	t.Helper()
+
+	t.Run("insert of a brand new key succeeds", func(t *testing.T) {
+		store := newStore()
+		ctx := context.Background()
+
+		k, err := store.Insert(ctx, idempotency.KeyParams{
+			Key:           "fresh",
+			RequestMethod: http.MethodPost,
+			RequestParams: []byte(`{"a":1}`),
+			RequestPath:   "/rides",
+			UserID:        1,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, idempotency.StartedRecoveryPoint, k.RecoveryPoint)
+	})
+
+	t.Run("re-inserting a still-live key returns the original instead of overwriting it", func(t *testing.T) {
+		store := newStore()
+		ctx := context.Background()
+
+		first, err := store.Insert(ctx, idempotency.KeyParams{
+			Key:           "live",
+			RequestMethod: http.MethodPost,
+			RequestParams: []byte(`{"a":1}`),
+			RequestPath:   "/rides",
+			UserID:        1,
+		})
+		require.NoError(t, err)
+
+		second, err := store.Insert(ctx, idempotency.KeyParams{
+			Key:           "live",
+			RequestMethod: http.MethodPost,
+			RequestParams: []byte(`{"a":2}`),
+			RequestPath:   "/rides",
+			UserID:        1,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, first.RequestParams, second.RequestParams, "second insert must not overwrite the still-live first key")
+	})
+
+	t.Run("concurrent inserts of the same key all agree on one winner", func(t *testing.T) {
+		store := newStore()
+		ctx := context.Background()
+
+		const n = 20
+		results := make([]*idempotency.Key, n)
+		var wg sync.WaitGroup
+		wg.Add(n)
+		for i := 0; i < n; i++ {
+			go func(i int) {
+				defer wg.Done()
+				k, err := store.Insert(ctx, idempotency.KeyParams{
+					Key:           "race",
+					RequestMethod: http.MethodPost,
+					RequestParams: []byte(fmt.Sprintf(`{"i":%d}`, i)),
+					RequestPath:   "/rides",
+					UserID:        1,
+				})
+				require.NoError(t, err)
+				results[i] = k
+			}(i)
+		}
+		wg.Wait()
+
+		want := results[0].RequestFingerprint
+		for i, k := range results {
+			assert.Equal(t, want, k.RequestFingerprint, "goroutine %d disagreed on the winning request", i)
+		}
+
+		stored, err := store.Find(ctx, 1, "race")
+		require.NoError(t, err)
+		assert.Equal(t, want, stored.RequestFingerprint, "the row actually persisted must match what every Insert call returned")
+	})
+
+	t.Run("re-inserting an expired key resets it", func(t *testing.T) {
+		store := newStore()
+		ctx := context.Background()
+
+		_, err := store.Insert(ctx, idempotency.KeyParams{
+			Key:           "expired",
+			RequestMethod: http.MethodPost,
+			RequestParams: []byte(`{"a":1}`),
+			RequestPath:   "/rides",
+			UserID:        1,
+			TTL:           time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+
+		reset, err := store.Insert(ctx, idempotency.KeyParams{
+			Key:           "expired",
+			RequestMethod: http.MethodPost,
+			RequestParams: []byte(`{"a":2}`),
+			RequestPath:   "/rides",
+			UserID:        1,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []byte(`{"a":2}`), reset.RequestParams, "insert must reset a row whose TTL already elapsed")
+		assert.Equal(t, idempotency.StartedRecoveryPoint, reset.RecoveryPoint)
+	})
+
+	t.Run("find returns ErrSQLNoRows for a key that was never inserted", func(t *testing.T) {
+		store := newStore()
+		ctx := context.Background()
+
+		_, err := store.Find(ctx, 1, "missing")
+		assert.ErrorIs(t, err, idempotency.ErrSQLNoRows)
+	})
+
+	t.Run("lock cannot be reacquired before its ttl elapses, and release requires the right token", func(t *testing.T) {
+		store := newStore()
+		ctx := context.Background()
+
+		_, err := store.Insert(ctx, idempotency.KeyParams{
+			Key:           "locked",
+			RequestMethod: http.MethodPost,
+			RequestParams: []byte(`{}`),
+			RequestPath:   "/rides",
+			UserID:        1,
+		})
+		require.NoError(t, err)
+
+		locked, lockToken, err := store.AcquireLock(ctx, 1, "locked", time.Minute)
+		require.NoError(t, err)
+		require.NotEmpty(t, lockToken)
+
+		_, _, err = store.AcquireLock(ctx, 1, "locked", time.Minute)
+		assert.ErrorIs(t, err, idempotency.ErrLockNotHeld)
+
+		err = store.ReleaseLock(ctx, locked, "wrong-token")
+		assert.ErrorIs(t, err, idempotency.ErrLockNotHeld)
+
+		err = store.ReleaseLock(ctx, locked, lockToken)
+		assert.NoError(t, err)
+
+		_, lockToken, err = store.AcquireLock(ctx, 1, "locked", time.Minute)
+		require.NoError(t, err, "lock must be reacquirable once released")
+		require.NotEmpty(t, lockToken)
+	})
+
+	t.Run("acquiring a lock on a key that doesn't exist fails with ErrLockNotHeld", func(t *testing.T) {
+		store := newStore()
+		ctx := context.Background()
+
+		_, _, err := store.AcquireLock(ctx, 1, "never-inserted", time.Minute)
+		assert.ErrorIs(t, err, idempotency.ErrLockNotHeld)
+	})
+}
@@ -0,0 +1,43 @@
+package send
+
+import (
+	"net/http"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+)
+
+// Error codes mirror idempotency.Code*: they're defined there (alongside
+// idempotency.Error/idempotency.ErrorFor) since idempotency.Middleware's
+// default error handling needs them and can't import send back without a
+// cycle, so send just re-exports them for existing callers.
+const (
+	CodeIdempotencyConflict  = idempotency.CodeIdempotencyConflict
+	CodeKeyLocked            = idempotency.CodeKeyLocked
+	CodeRecoveryPointUnknown = idempotency.CodeRecoveryPointUnknown
+	CodeInvalidParams        = idempotency.CodeInvalidParams
+	CodeInternal             = idempotency.CodeInternal
+)
+
+// Error is the machine-readable shape of every error response this service
+// sends, modeled on JSON-RPC's error object.
+type Error = idempotency.Error
+
+// WriteError writes e as a JSON body of the form {"error": {...}}, using
+// e.HTTPStatus as the response status.
+func WriteError(w http.ResponseWriter, e *Error) error {
+	return WriteJSON(w, e.HTTPStatus, struct {
+		Error *Error `json:"error"`
+	}{e})
+}
+
+// FromErr maps a sentinel error from the idempotency package to the
+// appropriate client-facing Error, so a caller's error handling reduces to
+// send.WriteError(w, send.FromErr(err)) instead of hand-rolling a status
+// code and body per call site. Errors it doesn't recognize map to
+// CodeInternal / 500. idempotency.Middleware's default OnError uses the
+// same mapping (idempotency.ErrorFor) internally, so a request rejected by
+// Middleware and one rejected by a handler calling send.FromErr directly
+// get an identical error envelope.
+func FromErr(err error) *Error {
+	return idempotency.ErrorFor(err)
+}
@@ -0,0 +1,46 @@
+package send_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anmho/idempotent-rides/idempotency"
+	"github.com/anmho/idempotent-rides/send"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromErr(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantCode   int
+		wantStatus int
+	}{
+		{"key conflict", idempotency.ErrKeyConflict, send.CodeIdempotencyConflict, http.StatusUnprocessableEntity},
+		{"lock not held", idempotency.ErrLockNotHeld, send.CodeKeyLocked, http.StatusConflict},
+		{"no rows", idempotency.ErrSQLNoRows, send.CodeInvalidParams, http.StatusBadRequest},
+		{"wrapped sentinel", fmt.Errorf("handling request: %w", idempotency.ErrKeyConflict), send.CodeIdempotencyConflict, http.StatusUnprocessableEntity},
+		{"unrecognized error", errors.New("boom"), send.CodeInternal, http.StatusInternalServerError},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := send.FromErr(tc.err)
+			assert.Equal(t, tc.wantCode, e.Code)
+			assert.Equal(t, tc.wantStatus, e.HTTPStatus)
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := send.WriteError(rec, send.FromErr(idempotency.ErrLockNotHeld))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"error":{"code":1002,"message":"idempotency key is locked by another in-flight request"}}`, rec.Body.String())
+}
@@ -0,0 +1,32 @@
+package send
+
+import (
+	"bytes"
+	"io"
+)
+
+// FingerprintReader wraps a request body, teeing every byte read through it
+// into an internal buffer. A handler can decode the body as normal via Read,
+// then call Bytes to get the raw body for idempotency fingerprinting without
+// having to buffer and re-read the body itself.
+type FingerprintReader struct {
+	r   io.Reader
+	buf bytes.Buffer
+}
+
+// NewFingerprintReader returns a FingerprintReader that tees r.
+func NewFingerprintReader(r io.Reader) *FingerprintReader {
+	fr := &FingerprintReader{}
+	fr.r = io.TeeReader(r, &fr.buf)
+	return fr
+}
+
+func (fr *FingerprintReader) Read(p []byte) (int, error) {
+	return fr.r.Read(p)
+}
+
+// Bytes returns the body read so far. Call it only after the body has been
+// fully consumed (e.g. after json.NewDecoder(fr).Decode(&v) returns).
+func (fr *FingerprintReader) Bytes() []byte {
+	return fr.buf.Bytes()
+}
@@ -0,0 +1,40 @@
+package send_test
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/anmho/idempotent-rides/send"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprintReader(t *testing.T) {
+	body := `{"a":1}`
+	fr := send.NewFingerprintReader(strings.NewReader(body))
+
+	var v struct {
+		A int `json:"a"`
+	}
+	require.NoError(t, json.NewDecoder(fr).Decode(&v))
+	assert.Equal(t, 1, v.A)
+	assert.Equal(t, body, string(fr.Bytes()))
+}
+
+func TestFingerprintReader_PartialReads(t *testing.T) {
+	body := "hello world"
+	fr := send.NewFingerprintReader(strings.NewReader(body))
+
+	buf := make([]byte, 5)
+	n, err := fr.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+
+	rest, err := io.ReadAll(fr)
+	require.NoError(t, err)
+	assert.Equal(t, " world", string(rest))
+
+	assert.Equal(t, body, string(fr.Bytes()))
+}